@@ -0,0 +1,238 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+	"triggerflow/config"
+	"triggerflow/eventsource"
+
+	cloudevents "github.com/cloudevents/sdk-go"
+	log "github.com/sirupsen/logrus"
+)
+
+// checkpointRecord is what gets enqueued once a trigger's Action fires
+// successfully. TriggerJSON is captured eagerly, at fire time, so replaying
+// the WAL after a crash never depends on the in-memory trigger cache having
+// been rebuilt yet.
+type checkpointRecord struct {
+	TriggerID       string `json:"triggerId"`
+	TriggerJSON     string `json:"triggerJson"`
+	EventSourceName string `json:"eventSourceName"`
+	Offset          string `json:"offset"`
+}
+
+// committer is the single background goroutine that turns fired-trigger
+// notifications into durable state: every record is appended to a local
+// WAL immediately (so a crash between action-fire and storage-commit can
+// be replayed), then batched and flushed to TriggerStorage/EventSource
+// every config.CheckpointBatchSize records or config.CheckpointBatchInterval,
+// whichever comes first.
+type committer struct {
+	workspace *Workspace
+	walPath   string
+
+	mu  sync.Mutex
+	wal *os.File
+
+	// done is closed once run returns, i.e. once workspace.CheckpointChannel
+	// has been closed and any trailing partial batch flushed.
+	done chan struct{}
+}
+
+func newCommitter(workspace *Workspace) (*committer, error) {
+	if err := os.MkdirAll(config.WALDir, 0o755); err != nil {
+		return nil, err
+	}
+
+	walPath := filepath.Join(config.WALDir, workspace.WorkspaceName+".wal")
+	wal, err := os.OpenFile(walPath, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, err
+	}
+
+	return &committer{workspace: workspace, walPath: walPath, wal: wal, done: make(chan struct{})}, nil
+}
+
+// replay commits whatever the WAL still holds from a previous run before
+// normal operation resumes, recovering state lost between a trigger action
+// firing and its checkpoint reaching storage.
+func (c *committer) replay() {
+	records, err := readWAL(c.walPath)
+	if err != nil {
+		log.Errorf("checkpoint: could not read WAL <%s>: %s", c.walPath, err)
+		return
+	}
+	if len(records) == 0 {
+		return
+	}
+
+	log.Infof("checkpoint: replaying %d record(s) from <%s>", len(records), c.walPath)
+	c.commitBatch(records)
+}
+
+func readWAL(path string) ([]checkpointRecord, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var records []checkpointRecord
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		var rec checkpointRecord
+		if err := json.Unmarshal([]byte(line), &rec); err != nil {
+			log.Errorf("checkpoint: skipping corrupt WAL line: %s", err)
+			continue
+		}
+		records = append(records, rec)
+	}
+	return records, nil
+}
+
+// run drains workspace.CheckpointChannel, appending each record to the WAL
+// as it arrives and flushing a batch once it reaches CheckpointBatchSize or
+// CheckpointBatchInterval elapses. It returns once the channel is closed
+// and any final partial batch has been flushed.
+func (c *committer) run() {
+	defer close(c.done)
+
+	ticker := time.NewTicker(config.CheckpointBatchInterval)
+	defer ticker.Stop()
+
+	var batch []checkpointRecord
+
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		c.commitBatch(batch)
+		batch = nil
+	}
+
+	for {
+		select {
+		case rec, ok := <-c.workspace.CheckpointChannel:
+			if !ok {
+				flush()
+				return
+			}
+			if err := c.appendWAL(rec); err != nil {
+				log.Errorf("checkpoint: could not append to WAL: %s", err)
+			}
+			batch = append(batch, rec)
+			if len(batch) >= config.CheckpointBatchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		}
+	}
+}
+
+func (c *committer) appendWAL(rec checkpointRecord) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	encoded, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	_, err = c.wal.Write(append(encoded, '\n'))
+	return err
+}
+
+// commitBatch persists every trigger referenced in batch and commits the
+// latest offset seen per event source, then truncates the WAL since the
+// batch is now durable in TriggerStorage.
+func (c *committer) commitBatch(batch []checkpointRecord) {
+	latestOffset := make(map[string]string, len(batch))
+	latestTriggerJSON := make(map[string]string, len(batch))
+
+	for _, rec := range batch {
+		if rec.TriggerJSON != "" {
+			latestTriggerJSON[rec.TriggerID] = rec.TriggerJSON
+		}
+		if rec.Offset != "" {
+			latestOffset[rec.EventSourceName] = rec.Offset
+		}
+	}
+
+	for triggerID, triggerJSON := range latestTriggerJSON {
+		c.workspace.TriggerStorage.Put(c.workspace.WorkspaceName, "triggers", triggerID, triggerJSON)
+	}
+
+	for name, offset := range latestOffset {
+		if name == "" {
+			log.Warnf("checkpoint: event carried no source name extension; offset %q was not committed", offset)
+			continue
+		}
+		source, ok := c.workspace.EventSources[name]
+		if !ok {
+			log.Warnf("checkpoint: unknown event source <%s>; offset %q was not committed", name, offset)
+			continue
+		}
+		if err := source.CommitOffset(offset); err != nil {
+			log.Errorf("checkpoint: could not commit offset on event source <%s>: %s", name, err)
+		}
+	}
+
+	c.truncateWAL()
+}
+
+func (c *committer) truncateWAL() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := c.wal.Truncate(0); err != nil {
+		log.Errorf("checkpoint: could not truncate WAL <%s>: %s", c.walPath, err)
+		return
+	}
+	if _, err := c.wal.Seek(0, 0); err != nil {
+		log.Errorf("checkpoint: could not rewind WAL <%s>: %s", c.walPath, err)
+	}
+}
+
+func (c *committer) close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.wal.Close()
+}
+
+// eventCheckpointInfo extracts which event source produced event and how
+// far it can be acknowledged. sourceName comes from the eventsource.
+// SourceNameExtension every EventSource stamps onto events it pushes --
+// not the CloudEvents "source" attribute, which is free-form (for
+// HTTPGateway it's caller-supplied) and has no required relationship to
+// the configured EventSources name a checkpoint needs to commit against.
+// Event sources that support per-event offsets are expected to set an
+// "offset" CloudEvents extension attribute; sources that don't fall back
+// to the event ID, which is still enough for CommitOffset implementations
+// that only care about "has this been seen".
+func eventCheckpointInfo(event *cloudevents.Event) (sourceName, offset string) {
+	if ext, ok := event.Extensions()[eventsource.SourceNameExtension]; ok {
+		if s, ok := ext.(string); ok {
+			sourceName = s
+		}
+	}
+
+	if ext, ok := event.Extensions()["offset"]; ok {
+		if s, ok := ext.(string); ok {
+			offset = s
+		}
+	}
+	if offset == "" {
+		offset = event.ID()
+	}
+
+	return sourceName, offset
+}
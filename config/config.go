@@ -0,0 +1,45 @@
+// Package config holds process-wide configuration, loaded once at startup.
+package config
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// SinkMaxSize bounds the buffer of the Workspace.EventSink channel.
+const SinkMaxSize = 1024
+
+// ShutdownGracePeriod bounds how long ProcessWorkspace waits for in-flight
+// trigger actions to finish draining once its context is canceled.
+const ShutdownGracePeriod = 30 * time.Second
+
+// CheckpointBatchSize and CheckpointBatchInterval bound how long the
+// background committer holds a checkpoint in its write-ahead log before
+// flushing it to TriggerStorage/EventSource, whichever limit hits first.
+const (
+	CheckpointBatchSize     = 20
+	CheckpointBatchInterval = 2 * time.Second
+)
+
+// WALDir is where each workspace's checkpoint write-ahead log is kept.
+const WALDir = "./data/wal"
+
+// DefaultMetricsAddr is the listen address ProcessWorkspace serves
+// Prometheus metrics on when Map.MetricsAddr is unset.
+const DefaultMetricsAddr = ":9090"
+
+type triggerStorageConfig struct {
+	Backend    string          `json:"backend"`
+	Parameters json.RawMessage `json:"parameters"`
+}
+
+type config struct {
+	TriggerStorage triggerStorageConfig `json:"triggerStorage"`
+
+	// MetricsAddr is where the trigger/queue/action Prometheus metrics
+	// are served. Defaults to DefaultMetricsAddr when empty.
+	MetricsAddr string `json:"metricsAddr,omitempty"`
+}
+
+// Map is the process's parsed configuration.
+var Map config
@@ -0,0 +1,45 @@
+// Package eventsource defines the event ingestion interface that
+// feeds a Workspace's EventSink, and the registry used to construct sources
+// by name from stored configuration.
+package eventsource
+
+import (
+	"encoding/json"
+
+	cloudevents "github.com/cloudevents/sdk-go"
+)
+
+// EventSource consumes events from some upstream (a broker, a poller, a
+// webhook server, ...) and pushes them onto the channel it was constructed
+// with.
+type EventSource interface {
+	// StartConsuming blocks, pushing events onto the sink until the
+	// source is torn down.
+	StartConsuming()
+
+	// CommitEvents acknowledges/commits whatever has been delivered so
+	// far, so it is not redelivered after a restart.
+	CommitEvents()
+
+	// CommitOffset acknowledges delivery up to a single, source-specific
+	// offset (a Kafka offset, a Redis stream ID, ...), for sources that
+	// can commit more granularly than "everything so far".
+	CommitOffset(offset string) error
+}
+
+// Constructor builds an EventSource for a given workspace, wired to push
+// events onto sink, configured by the source-specific params blob.
+// sourceName is the operator-configured "Name" of this source in the
+// event_sources collection; implementations should stamp it onto every
+// event they push (see SourceNameExtension) so the checkpoint committer
+// can later commit offsets against the source that actually produced an
+// event, rather than its free-form CloudEvents "source" attribute.
+type Constructor func(workspaceName, sourceName string, sink chan *cloudevents.Event, params json.RawMessage) EventSource
+
+// SourceNameExtension is the CloudEvents extension attribute an EventSource
+// sets to the sourceName it was constructed with.
+const SourceNameExtension = "sourcename"
+
+// Constructors is the registry of EventSource implementations, keyed by the
+// "Class" field of a stored event source definition.
+var Constructors = map[string]Constructor{}
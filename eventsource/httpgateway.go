@@ -0,0 +1,185 @@
+package eventsource
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"triggerflow/config"
+
+	cloudevents "github.com/cloudevents/sdk-go"
+	"github.com/xeipuuv/gojsonschema"
+)
+
+const defaultSignatureHeader = "X-TriggerFlow-Signature"
+
+// httpGatewayConfig is the Parameters blob for the "HTTPGateway" class of
+// event source.
+type httpGatewayConfig struct {
+	Addr            string          `json:"addr"`
+	Path            string          `json:"path"`
+	SharedSecret    string          `json:"sharedSecret"`
+	SignatureHeader string          `json:"signatureHeader,omitempty"`
+	Schema          json.RawMessage `json:"schema,omitempty"`
+}
+
+// HTTPGateway is a push-based EventSource: it runs an HTTP server that
+// accepts CloudEvents over the HTTP protocol binding (both structured and
+// binary content modes), verifies an HMAC-SHA256 signature against a
+// shared secret, optionally validates the payload against a JSON schema,
+// and forwards the decoded event onto the workspace's EventSink.
+type HTTPGateway struct {
+	config     httpGatewayConfig
+	sourceName string
+	sink       chan *cloudevents.Event
+	server     *http.Server
+	schema     *gojsonschema.Schema
+}
+
+// NewHTTPGateway builds an HTTPGateway EventSource from its JSON
+// parameters. Registered as eventsource.Constructors["HTTPGateway"].
+func NewHTTPGateway(workspaceName, sourceName string, sink chan *cloudevents.Event, params json.RawMessage) EventSource {
+	var cfg httpGatewayConfig
+	if err := json.Unmarshal(params, &cfg); err != nil {
+		panic(fmt.Errorf("HTTPGateway: invalid parameters: %w", err))
+	}
+	if cfg.SignatureHeader == "" {
+		cfg.SignatureHeader = defaultSignatureHeader
+	}
+
+	gw := &HTTPGateway{config: cfg, sourceName: sourceName, sink: sink}
+
+	if len(cfg.Schema) > 0 {
+		schema, err := gojsonschema.NewSchema(gojsonschema.NewBytesLoader(cfg.Schema))
+		if err != nil {
+			panic(fmt.Errorf("HTTPGateway: invalid payload schema: %w", err))
+		}
+		gw.schema = schema
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(cfg.Path, gw.handle)
+	gw.server = &http.Server{Addr: cfg.Addr, Handler: mux}
+
+	return gw
+}
+
+// StartConsuming runs the HTTP server until it is shut down. It blocks, as
+// every other EventSource's StartConsuming does.
+func (gw *HTTPGateway) StartConsuming() {
+	if err := gw.server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		panic(fmt.Errorf("HTTPGateway: %w", err))
+	}
+}
+
+// CommitEvents is a no-op: every accepted request is already acknowledged
+// by its HTTP response, so there is nothing left to commit.
+func (gw *HTTPGateway) CommitEvents() {}
+
+// CommitOffset is a no-op for the same reason: a push-based source has no
+// notion of a replayable offset to advance.
+func (gw *HTTPGateway) CommitOffset(offset string) error { return nil }
+
+func (gw *HTTPGateway) handle(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, "could not read body", http.StatusBadRequest)
+		return
+	}
+
+	if !gw.verifySignature(r, body) {
+		http.Error(w, "invalid signature", http.StatusUnauthorized)
+		return
+	}
+
+	event, err := gw.decodeEvent(r, body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if gw.schema != nil {
+		if err := gw.validateSchema(event); err != nil {
+			http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+			return
+		}
+	}
+
+	event.SetExtension(SourceNameExtension, gw.sourceName)
+
+	gw.sink <- event
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func (gw *HTTPGateway) verifySignature(r *http.Request, body []byte) bool {
+	provided := r.Header.Get(gw.config.SignatureHeader)
+	if provided == "" {
+		return false
+	}
+
+	mac := hmac.New(sha256.New, []byte(gw.config.SharedSecret))
+	mac.Write(body)
+	expected := hex.EncodeToString(mac.Sum(nil))
+
+	return hmac.Equal([]byte(provided), []byte(expected))
+}
+
+// decodeEvent supports both CloudEvents HTTP content modes: structured
+// (the whole body is a CloudEvents JSON document) and binary (CloudEvents
+// attributes arrive as "ce-*" headers, the body is just the data).
+func (gw *HTTPGateway) decodeEvent(r *http.Request, body []byte) (*cloudevents.Event, error) {
+	if r.Header.Get("Content-Type") == cloudevents.ApplicationCloudEventsJSON {
+		event := cloudevents.NewEvent()
+		if err := json.Unmarshal(body, &event); err != nil {
+			return nil, fmt.Errorf("structured mode: decode event: %w", err)
+		}
+		return &event, nil
+	}
+
+	event := cloudevents.NewEvent()
+	event.SetID(r.Header.Get("ce-id"))
+	event.SetSource(r.Header.Get("ce-source"))
+	event.SetType(r.Header.Get("ce-type"))
+	event.SetSubject(r.Header.Get("ce-subject"))
+	if len(body) > 0 {
+		if err := event.SetData(json.RawMessage(body)); err != nil {
+			return nil, fmt.Errorf("binary mode: set data: %w", err)
+		}
+	}
+	return &event, nil
+}
+
+func (gw *HTTPGateway) validateSchema(event *cloudevents.Event) error {
+	data, err := event.DataBytes()
+	if err != nil {
+		return fmt.Errorf("validate payload: read event data: %w", err)
+	}
+
+	result, err := gw.schema.Validate(gojsonschema.NewBytesLoader(data))
+	if err != nil {
+		return fmt.Errorf("validate payload: %w", err)
+	}
+	if !result.Valid() {
+		return fmt.Errorf("payload failed schema validation: %v", result.Errors())
+	}
+	return nil
+}
+
+// Close drains and shuts down the HTTP server, bounded by
+// config.ShutdownGracePeriod. Exported (and named Close, not shutdown) so
+// Workspace.shutdown's `interface{ Close() error }` check actually finds
+// it.
+func (gw *HTTPGateway) Close() error {
+	ctx, cancel := context.WithTimeout(context.Background(), config.ShutdownGracePeriod)
+	defer cancel()
+	return gw.server.Shutdown(ctx)
+}
+
+func init() {
+	Constructors["HTTPGateway"] = NewHTTPGateway
+}
@@ -0,0 +1,48 @@
+// Package tirggerstorage persists workspaces, triggers, event sources and
+// global context to a pluggable backend (the misspelling is historical and
+// kept for import-path stability).
+package tirggerstorage
+
+import "encoding/json"
+
+// Storage is the persistence backend for a workspace's triggers, event
+// sources and global context.
+type Storage interface {
+	// Get returns every key/value pair stored under workspace/collection,
+	// e.g. (workspaceName, "triggers") -> {triggerID: triggerJSON}.
+	Get(workspace, collection string) map[string]string
+
+	// Put stores a single key/value pair under workspace/collection.
+	Put(workspace, collection, key, value string)
+
+	// Watch streams changes to workspace/collection as they happen (e.g.
+	// via Redis keyspace notifications or PostgreSQL LISTEN/NOTIFY),
+	// letting a caller react to new/updated/deleted triggers without
+	// polling. The returned channel is closed once watching stops being
+	// possible (backend shutdown, connection loss).
+	Watch(workspace, collection string) <-chan StorageEvent
+}
+
+// StorageEventType distinguishes a StorageEvent's kind of change.
+type StorageEventType string
+
+const (
+	StorageEventPut    StorageEventType = "put"
+	StorageEventDelete StorageEventType = "delete"
+)
+
+// StorageEvent is a single change to a key within workspace/collection.
+// Value is empty for StorageEventDelete.
+type StorageEvent struct {
+	Type  StorageEventType
+	Key   string
+	Value string
+}
+
+// BackendConstructor builds a Storage backend from its configuration
+// parameters.
+type BackendConstructor func(parameters json.RawMessage) Storage
+
+// BackendConstructors is the registry of Storage backends, keyed by the
+// config.Map.TriggerStorage.Backend name.
+var BackendConstructors = map[string]BackendConstructor{}
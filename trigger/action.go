@@ -0,0 +1,156 @@
+package trigger
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	cloudevents "github.com/cloudevents/sdk-go"
+)
+
+// ActionSpec is the declarative, JSON-authorable counterpart to a
+// hand-registered ActionFunc. Exactly one of the typed fields below should
+// be set; "Type" picks which.
+type ActionSpec struct {
+	Type string `json:"type"`
+
+	// "emit": publish a CloudEvent onto the workspace's EventSink.
+	Emit *EmitAction `json:"emit,omitempty"`
+
+	// "webhook": invoke an HTTP endpoint.
+	Webhook *WebhookAction `json:"webhook,omitempty"`
+
+	// "publish": publish to a message broker (Kafka/Redis) backed event
+	// source, identified by name.
+	Publish *PublishAction `json:"publish,omitempty"`
+
+	// "setContext": set a key in the workspace's GlobalContext.
+	SetContext *SetContextAction `json:"setContext,omitempty"`
+}
+
+// EmitAction publishes a new CloudEvent onto the workspace's own EventSink,
+// so it re-enters the normal trigger matching path. Subject, Type and Data
+// are used exactly as configured; nothing is substituted from the
+// triggering event.
+type EmitAction struct {
+	Subject string          `json:"subject"`
+	Type    string          `json:"type"`
+	Data    json.RawMessage `json:"data"`
+}
+
+// WebhookAction performs a simple HTTP call.
+type WebhookAction struct {
+	URL     string            `json:"url"`
+	Method  string            `json:"method"`
+	Headers map[string]string `json:"headers,omitempty"`
+}
+
+// PublishAction hands the event off to a named, pre-configured EventSource
+// that knows how to publish (e.g. a Kafka or Redis backed source).
+type PublishAction struct {
+	EventSourceName string `json:"eventSourceName"`
+	Topic           string `json:"topic"`
+}
+
+// SetContextAction writes a value into GlobalContext[Namespace][Key].
+type SetContextAction struct {
+	Namespace string      `json:"namespace"`
+	Key       string      `json:"key"`
+	Value     interface{} `json:"value"`
+}
+
+// compile turns the declarative spec into a plain ActionFunc closure,
+// resolved once at load time instead of being branched on per event.
+func (spec *ActionSpec) compile() (ActionFunc, error) {
+	switch spec.Type {
+	case "emit":
+		if spec.Emit == nil {
+			return nil, fmt.Errorf("actionSpec type %q missing emit config", spec.Type)
+		}
+		return spec.Emit.execute, nil
+	case "webhook":
+		if spec.Webhook == nil {
+			return nil, fmt.Errorf("actionSpec type %q missing webhook config", spec.Type)
+		}
+		return spec.Webhook.execute, nil
+	case "publish":
+		if spec.Publish == nil {
+			return nil, fmt.Errorf("actionSpec type %q missing publish config", spec.Type)
+		}
+		return spec.Publish.execute, nil
+	case "setContext":
+		if spec.SetContext == nil {
+			return nil, fmt.Errorf("actionSpec type %q missing setContext config", spec.Type)
+		}
+		return spec.SetContext.execute, nil
+	default:
+		return nil, fmt.Errorf("unknown actionSpec type %q", spec.Type)
+	}
+}
+
+func (a *EmitAction) execute(_ context.Context, tctx Context, _ cloudevents.Event) error {
+	out := cloudevents.NewEvent()
+	out.SetSubject(a.Subject)
+	out.SetType(a.Type)
+	if len(a.Data) > 0 {
+		if err := out.SetData(a.Data); err != nil {
+			return fmt.Errorf("emit action: set data: %w", err)
+		}
+	}
+	tctx.EventSink <- &out
+	return nil
+}
+
+func (a *WebhookAction) execute(runCtx context.Context, _ Context, event cloudevents.Event) error {
+	method := a.Method
+	if method == "" {
+		method = http.MethodPost
+	}
+
+	data, err := event.DataBytes()
+	if err != nil {
+		return fmt.Errorf("webhook action: read event data: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(runCtx, method, a.URL, bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("webhook action: build request: %w", err)
+	}
+	for key, value := range a.Headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("webhook action: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		return fmt.Errorf("webhook action: %s returned status %d", a.URL, resp.StatusCode)
+	}
+	return nil
+}
+
+func (a *PublishAction) execute(_ context.Context, tctx Context, event cloudevents.Event) error {
+	source, ok := tctx.EventSources[a.EventSourceName]
+	if !ok {
+		return fmt.Errorf("publish action: unknown event source %q", a.EventSourceName)
+	}
+
+	publisher, ok := source.(interface {
+		Publish(topic string, event cloudevents.Event) error
+	})
+	if !ok {
+		return fmt.Errorf("publish action: event source %q does not support publishing", a.EventSourceName)
+	}
+
+	return publisher.Publish(a.Topic, event)
+}
+
+func (a *SetContextAction) execute(_ context.Context, tctx Context, _ cloudevents.Event) error {
+	tctx.GlobalContext.Set(a.Namespace, a.Key, a.Value)
+	return nil
+}
@@ -0,0 +1,106 @@
+package trigger
+
+import (
+	cloudevents "github.com/cloudevents/sdk-go"
+	"golang.org/x/time/rate"
+)
+
+// RateLimit configures a token-bucket limiter shared by all of a trigger's
+// workers.
+type RateLimit struct {
+	QPS   float64 `json:"qps"`
+	Burst int     `json:"burst"`
+}
+
+// NewLimiter builds the rate.Limiter for this trigger, or nil if the
+// trigger has no RateLimit configured.
+func (r *RateLimit) NewLimiter() *rate.Limiter {
+	if r == nil || r.QPS <= 0 {
+		return nil
+	}
+	burst := r.Burst
+	if burst < 1 {
+		burst = 1
+	}
+	return rate.NewLimiter(rate.Limit(r.QPS), burst)
+}
+
+// OverflowPolicy decides what Enqueue does when a trigger's EventChannel is
+// full.
+type OverflowPolicy string
+
+const (
+	// OverflowBlock waits for room in the channel, same as the original
+	// unbounded processTrigger behavior.
+	OverflowBlock OverflowPolicy = "block"
+	// OverflowDropNew discards the incoming event, keeping everything
+	// already queued.
+	OverflowDropNew OverflowPolicy = "drop-new"
+	// OverflowDropOldest discards the oldest queued event to make room
+	// for the incoming one.
+	OverflowDropOldest OverflowPolicy = "drop-oldest"
+)
+
+// Limiter builds a fresh rate.Limiter for this trigger's RateLimit config,
+// or nil if it has none configured. processTrigger builds one per trigger
+// and shares it across the trigger's worker pool.
+func (trg *Trigger) Limiter() *rate.Limiter {
+	return trg.RateLimit.NewLimiter()
+}
+
+// Enqueue pushes event onto the trigger's EventChannel according to its
+// OverflowPolicy, recording a drop metric whenever an event doesn't make it
+// onto the channel. It is a no-op once the trigger has been Close()d, so a
+// hot-reload delete racing with in-flight dispatch can never send on a
+// closed channel.
+func (trg *Trigger) Enqueue(event *cloudevents.Event) {
+	trg.closeMu.RLock()
+	defer trg.closeMu.RUnlock()
+	if trg.closed {
+		return
+	}
+
+	trg.metrics.observeQueueDepth(len(trg.EventChannel))
+
+	switch trg.OverflowPolicy {
+	case OverflowDropNew:
+		select {
+		case trg.EventChannel <- event:
+		default:
+			trg.metrics.incDropped()
+		}
+	case OverflowDropOldest:
+		select {
+		case trg.EventChannel <- event:
+		default:
+			select {
+			case <-trg.EventChannel:
+				trg.metrics.incDropped()
+			default:
+			}
+			select {
+			case trg.EventChannel <- event:
+			default:
+				trg.metrics.incDropped()
+			}
+		}
+	default: // OverflowBlock
+		trg.EventChannel <- event
+	}
+}
+
+// Close marks the trigger closed and closes its EventChannel. Safe to call
+// concurrently with Enqueue and safe to call more than once: Enqueue holds
+// closeMu for the duration of its send, so any send already in flight when
+// Close acquires the lock completes first, and an Enqueue that arrives
+// after sees closed and returns without touching the channel.
+func (trg *Trigger) Close() {
+	trg.closeMu.Lock()
+	defer trg.closeMu.Unlock()
+
+	if trg.closed {
+		return
+	}
+	trg.closed = true
+	close(trg.EventChannel)
+}
@@ -0,0 +1,99 @@
+package trigger
+
+import (
+	"fmt"
+
+	cloudevents "github.com/cloudevents/sdk-go"
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/common/types"
+	"github.com/google/cel-go/common/types/ref"
+)
+
+// celEnv is shared across all compiled conditions: it only declares the
+// variables exposed to trigger expressions, it holds no per-trigger state.
+var celEnv = mustNewCELEnv()
+
+func mustNewCELEnv() *cel.Env {
+	env, err := cel.NewEnv(
+		cel.Variable("subject", cel.StringType),
+		cel.Variable("type", cel.StringType),
+		cel.Variable("data", cel.DynType),
+		cel.Variable("context", cel.MapType(cel.StringType, cel.DynType)),
+	)
+	if err != nil {
+		panic(fmt.Errorf("trigger: building CEL environment: %w", err))
+	}
+	return env
+}
+
+// compiledCondition wraps a CEL program compiled from a trigger's
+// ConditionExpr. It is built once, at load time, and reused for every event
+// the trigger sees.
+type compiledCondition struct {
+	expr    string
+	program cel.Program
+}
+
+// compileCondition parses and checks expr against celEnv and produces a
+// reusable, evaluable program. Compilation happens once per trigger load;
+// Evaluate never re-parses the expression.
+func compileCondition(expr string) (*compiledCondition, error) {
+	ast, issues := celEnv.Compile(expr)
+	if issues != nil && issues.Err() != nil {
+		return nil, fmt.Errorf("parse condition %q: %w", expr, issues.Err())
+	}
+
+	if ast.OutputType() != cel.BoolType {
+		return nil, fmt.Errorf("condition %q does not evaluate to a bool", expr)
+	}
+
+	program, err := celEnv.Program(ast)
+	if err != nil {
+		return nil, fmt.Errorf("plan condition %q: %w", expr, err)
+	}
+
+	return &compiledCondition{expr: expr, program: program}, nil
+}
+
+// Evaluate runs the compiled condition against a single event. It never
+// reflects over the event or looks anything up by name: every input it
+// needs was already extracted into the activation vars map.
+func (c *compiledCondition) Evaluate(ctx Context, event cloudevents.Event) (bool, error) {
+	var data interface{}
+	if err := event.DataAs(&data); err != nil {
+		data = nil
+	}
+
+	out, _, err := c.program.Eval(map[string]interface{}{
+		"subject": event.Subject(),
+		"type":    event.Type(),
+		"data":    data,
+		"context": ctx.GlobalContext.Snapshot(),
+	})
+	if err != nil {
+		return false, fmt.Errorf("evaluate condition %q: %w", c.expr, err)
+	}
+
+	result, ok := out.(ref.Val)
+	if !ok {
+		return false, fmt.Errorf("condition %q produced a non-ref.Val result", c.expr)
+	}
+
+	boolResult, ok := result.(types.Bool)
+	if !ok {
+		return false, fmt.Errorf("condition %q did not produce a bool", c.expr)
+	}
+
+	return bool(boolResult), nil
+}
+
+// CompileCondition exposes the same CEL compilation used for a Trigger's
+// ConditionExpr to other packages (e.g. workflow "if" guards) that need to
+// evaluate a boolean expression against a CloudEvent outside of a Trigger.
+func CompileCondition(expr string) (ConditionFunc, error) {
+	compiled, err := compileCondition(expr)
+	if err != nil {
+		return nil, err
+	}
+	return compiled.Evaluate, nil
+}
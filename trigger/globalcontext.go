@@ -0,0 +1,53 @@
+package trigger
+
+import "sync"
+
+// GlobalContext is the workspace-wide, namespace/key scoped state every
+// trigger's Condition/Action closures share. It is read concurrently by
+// every trigger's condition evaluation (Snapshot) and written concurrently
+// by SetContextAction, so access goes through mu rather than a plain map.
+type GlobalContext struct {
+	mu   sync.RWMutex
+	data map[string]map[string]interface{}
+}
+
+// NewGlobalContext returns an empty GlobalContext ready to use.
+func NewGlobalContext() *GlobalContext {
+	return &GlobalContext{data: make(map[string]map[string]interface{})}
+}
+
+// SetNamespace replaces an entire namespace, e.g. when loading stored
+// global context at workspace startup.
+func (gc *GlobalContext) SetNamespace(namespace string, values map[string]interface{}) {
+	gc.mu.Lock()
+	defer gc.mu.Unlock()
+	gc.data[namespace] = values
+}
+
+// Set writes a single key within namespace, creating the namespace if it
+// doesn't exist yet.
+func (gc *GlobalContext) Set(namespace, key string, value interface{}) {
+	gc.mu.Lock()
+	defer gc.mu.Unlock()
+	if _, ok := gc.data[namespace]; !ok {
+		gc.data[namespace] = make(map[string]interface{})
+	}
+	gc.data[namespace][key] = value
+}
+
+// Snapshot returns a shallow copy of every namespace, safe for a caller to
+// range over without holding gc's lock.
+func (gc *GlobalContext) Snapshot() map[string]interface{} {
+	gc.mu.RLock()
+	defer gc.mu.RUnlock()
+
+	flat := make(map[string]interface{}, len(gc.data))
+	for namespace, values := range gc.data {
+		copied := make(map[string]interface{}, len(values))
+		for key, value := range values {
+			copied[key] = value
+		}
+		flat[namespace] = copied
+	}
+	return flat
+}
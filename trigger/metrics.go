@@ -0,0 +1,73 @@
+package trigger
+
+import "github.com/prometheus/client_golang/prometheus"
+
+var (
+	queueDepthGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "triggerflow",
+		Subsystem: "trigger",
+		Name:      "queue_depth",
+		Help:      "Number of events currently buffered in a trigger's EventChannel.",
+	}, []string{"trigger_id"})
+
+	dropsCounter = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "triggerflow",
+		Subsystem: "trigger",
+		Name:      "dropped_events_total",
+		Help:      "Events dropped from a trigger's queue due to its overflow policy.",
+	}, []string{"trigger_id"})
+
+	actionLatencyHistogram = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "triggerflow",
+		Subsystem: "trigger",
+		Name:      "action_latency_seconds",
+		Help:      "Latency of a trigger's Action call.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"trigger_id"})
+)
+
+func init() {
+	prometheus.MustRegister(queueDepthGauge, dropsCounter, actionLatencyHistogram)
+}
+
+// triggerMetrics bundles the per-trigger-ID Prometheus child collectors so
+// the rest of the package doesn't have to thread a trigger_id label around.
+type triggerMetrics struct {
+	queueDepth    prometheus.Gauge
+	drops         prometheus.Counter
+	actionLatency prometheus.Observer
+}
+
+func newTriggerMetrics(triggerID string) *triggerMetrics {
+	return &triggerMetrics{
+		queueDepth:    queueDepthGauge.WithLabelValues(triggerID),
+		drops:         dropsCounter.WithLabelValues(triggerID),
+		actionLatency: actionLatencyHistogram.WithLabelValues(triggerID),
+	}
+}
+
+func (m *triggerMetrics) observeQueueDepth(depth int) {
+	if m == nil {
+		return
+	}
+	m.queueDepth.Set(float64(depth))
+}
+
+func (m *triggerMetrics) incDropped() {
+	if m == nil {
+		return
+	}
+	m.drops.Inc()
+}
+
+func (m *triggerMetrics) observeActionLatency(seconds float64) {
+	if m == nil {
+		return
+	}
+	m.actionLatency.Observe(seconds)
+}
+
+// ObserveActionLatency records how long a single Action invocation took.
+func (trg *Trigger) ObserveActionLatency(seconds float64) {
+	trg.metrics.observeActionLatency(seconds)
+}
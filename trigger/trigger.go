@@ -0,0 +1,242 @@
+// Package trigger defines the core Trigger type and the machinery used to
+// turn a stored JSON trigger definition into something a Workspace can
+// evaluate against incoming CloudEvents.
+package trigger
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"triggerflow/eventsource"
+
+	cloudevents "github.com/cloudevents/sdk-go"
+)
+
+// ConditionFunc decides whether a Trigger's Action should fire for the given
+// event.
+type ConditionFunc func(ctx Context, event cloudevents.Event) (bool, error)
+
+// ActionFunc carries out whatever a Trigger does once its condition is met.
+// runCtx is the per-invocation context fireTrigger builds from the
+// trigger's Timeout (if any); unlike tctx it is not safe to retain past a
+// single call.
+type ActionFunc func(runCtx context.Context, tctx Context, event cloudevents.Event) error
+
+// Context is the state a Trigger's Condition/Action closures can see. It is
+// populated by Workspace.contextualizeTrigger before the trigger is ever
+// evaluated.
+type Context struct {
+	EventSink           chan *cloudevents.Event
+	EventSources        map[string]eventsource.EventSource
+	Triggers            Map
+	TriggerEventMapping ActivationEventMap
+	GlobalContext       *GlobalContext
+
+	RawData             json.RawMessage
+	ConditionParsedData interface{}
+	ActionParsedData    interface{}
+}
+
+// Map indexes Triggers by TriggerID.
+type Map map[string]*Trigger
+
+// ActivationEventMap indexes the Triggers interested in an event by the
+// event's Subject and then Type.
+type ActivationEventMap map[string]map[string][]*Trigger
+
+// ContextParserFunc parses a Trigger's RawData into whatever shape a named
+// condition/action parser expects.
+type ContextParserFunc func(raw json.RawMessage) (interface{}, error)
+
+// ContextParsers is the registry of condition/action parsers, keyed by the
+// "name" field of ConditionFunctionData/ActionFunctionData. It is the
+// extension point used before triggers could carry their own CEL
+// expressions and action specs.
+var ContextParsers = map[string]ContextParserFunc{}
+
+// Conditions and Actions are the legacy by-name dispatch tables: a Trigger
+// without a ConditionExpr/ActionSpec resolves its Condition/Action here
+// using ConditionFunctionData["name"]/ActionFunctionData["name"].
+var Conditions = map[string]ConditionFunc{}
+var Actions = map[string]ActionFunc{}
+
+// Trigger is a single unit of condition -> action dispatch. It is loaded
+// from JSON (see UnmarshalJSONTrigger) and, once contextualized by a
+// Workspace, can be evaluated against events on its EventChannel.
+type Trigger struct {
+	TriggerID        string
+	UUID             string
+	Name             string
+	ActivationEvents []cloudevents.Event
+
+	// Legacy dispatch-by-name extension point. The "name" entry is
+	// looked up in both ContextParsers (to produce parsed config data)
+	// and Conditions/Actions (to resolve the actual closure to run).
+	ConditionFunctionData map[string]string
+	ActionFunctionData    map[string]string
+
+	// Declarative condition/action definition. When set, these are
+	// compiled once (see compileCondition) instead of relying on a
+	// registered Go parser/closure.
+	ConditionExpr string      `json:"conditionExpr,omitempty"`
+	ActionSpec    *ActionSpec `json:"actionSpec,omitempty"`
+
+	// Concurrency, RateLimit and QueueSize bound how processTrigger
+	// drains EventChannel: Concurrency workers, each gated by RateLimit,
+	// pulling from a channel of QueueSize capacity. OverflowPolicy
+	// decides what Enqueue does once that channel is full.
+	Concurrency    int            `json:"concurrency,omitempty"`
+	RateLimit      *RateLimit     `json:"rateLimit,omitempty"`
+	QueueSize      int            `json:"queueSize,omitempty"`
+	OverflowPolicy OverflowPolicy `json:"overflowPolicy,omitempty"`
+
+	// Timeout, if set, bounds a single invocation of Action: the caller
+	// wraps it in context.WithTimeout before running it.
+	Timeout time.Duration `json:"-"`
+
+	Context Context `json:"-"`
+
+	EventChannel chan *cloudevents.Event `json:"-"`
+	Condition    ConditionFunc           `json:"-"`
+	Action       ActionFunc              `json:"-"`
+
+	// compiledCondition caches the compiled CEL program for ConditionExpr
+	// so it is only parsed/checked once, at load time.
+	compiledCondition *compiledCondition
+
+	metrics *triggerMetrics
+
+	// closeMu guards closed, so Enqueue and Close (hot-reload deletion)
+	// can never race on EventChannel.
+	closeMu sync.RWMutex
+	closed  bool
+}
+
+type jsonTrigger struct {
+	TriggerID             string              `json:"triggerId"`
+	UUID                  string              `json:"uuid"`
+	Name                  string              `json:"name"`
+	ActivationEvents      []cloudevents.Event `json:"activationEvents"`
+	ConditionFunctionData map[string]string   `json:"conditionFunctionData,omitempty"`
+	ActionFunctionData    map[string]string   `json:"actionFunctionData,omitempty"`
+	ConditionExpr         string              `json:"conditionExpr,omitempty"`
+	ActionSpec            *ActionSpec         `json:"actionSpec,omitempty"`
+	Concurrency           int                 `json:"concurrency,omitempty"`
+	RateLimit             *RateLimit          `json:"rateLimit,omitempty"`
+	QueueSize             int                 `json:"queueSize,omitempty"`
+	OverflowPolicy        OverflowPolicy      `json:"overflowPolicy,omitempty"`
+	Timeout               string              `json:"timeout,omitempty"`
+	RawData               json.RawMessage     `json:"rawData,omitempty"`
+}
+
+const (
+	// DefaultConcurrency is used when a trigger doesn't specify one.
+	DefaultConcurrency = 1
+	// DefaultQueueSize is the EventChannel buffer used when a trigger
+	// doesn't specify a QueueSize.
+	DefaultQueueSize = 64
+)
+
+// UnmarshalJSONTrigger decodes a stored trigger definition and, if it
+// carries a declarative ConditionExpr/ActionSpec, compiles them immediately
+// so that per-event evaluation never has to parse or look anything up by
+// name.
+func UnmarshalJSONTrigger(data []byte) (*Trigger, error) {
+	var jt jsonTrigger
+	if err := json.Unmarshal(data, &jt); err != nil {
+		return nil, fmt.Errorf("unmarshal trigger: %w", err)
+	}
+
+	concurrency := jt.Concurrency
+	if concurrency < 1 {
+		concurrency = DefaultConcurrency
+	}
+
+	queueSize := jt.QueueSize
+	if queueSize < 1 {
+		queueSize = DefaultQueueSize
+	}
+
+	overflowPolicy := jt.OverflowPolicy
+	if overflowPolicy == "" {
+		overflowPolicy = OverflowBlock
+	}
+
+	trg := &Trigger{
+		TriggerID:             jt.TriggerID,
+		UUID:                  jt.UUID,
+		Name:                  jt.Name,
+		ActivationEvents:      jt.ActivationEvents,
+		ConditionFunctionData: jt.ConditionFunctionData,
+		ActionFunctionData:    jt.ActionFunctionData,
+		ConditionExpr:         jt.ConditionExpr,
+		ActionSpec:            jt.ActionSpec,
+		Concurrency:           concurrency,
+		RateLimit:             jt.RateLimit,
+		QueueSize:             queueSize,
+		OverflowPolicy:        overflowPolicy,
+		EventChannel:          make(chan *cloudevents.Event, queueSize),
+	}
+	trg.Context.RawData = jt.RawData
+	trg.metrics = newTriggerMetrics(trg.TriggerID)
+
+	if jt.Timeout != "" {
+		timeout, err := time.ParseDuration(jt.Timeout)
+		if err != nil {
+			return nil, fmt.Errorf("parse timeout for trigger <%s>: %w", trg.TriggerID, err)
+		}
+		trg.Timeout = timeout
+	}
+
+	if trg.ConditionExpr != "" {
+		compiled, err := compileCondition(trg.ConditionExpr)
+		if err != nil {
+			return nil, fmt.Errorf("compile condition for trigger <%s>: %w", trg.TriggerID, err)
+		}
+		trg.compiledCondition = compiled
+		trg.Condition = trg.compiledCondition.Evaluate
+	} else if name := trg.ConditionFunctionData["name"]; name != "" {
+		trg.Condition = Conditions[name]
+	}
+
+	if trg.ActionSpec != nil {
+		executor, err := trg.ActionSpec.compile()
+		if err != nil {
+			return nil, fmt.Errorf("compile action for trigger <%s>: %w", trg.TriggerID, err)
+		}
+		trg.Action = executor
+	} else if name := trg.ActionFunctionData["name"]; name != "" {
+		trg.Action = Actions[name]
+	}
+
+	return trg, nil
+}
+
+// MarshalJSONTrigger encodes a Trigger back into its stored JSON form.
+func MarshalJSONTrigger(trg *Trigger) ([]byte, error) {
+	var timeout string
+	if trg.Timeout > 0 {
+		timeout = trg.Timeout.String()
+	}
+
+	jt := jsonTrigger{
+		TriggerID:             trg.TriggerID,
+		UUID:                  trg.UUID,
+		Name:                  trg.Name,
+		ActivationEvents:      trg.ActivationEvents,
+		ConditionFunctionData: trg.ConditionFunctionData,
+		ActionFunctionData:    trg.ActionFunctionData,
+		ConditionExpr:         trg.ConditionExpr,
+		ActionSpec:            trg.ActionSpec,
+		Concurrency:           trg.Concurrency,
+		RateLimit:             trg.RateLimit,
+		QueueSize:             trg.QueueSize,
+		OverflowPolicy:        trg.OverflowPolicy,
+		Timeout:               timeout,
+		RawData:               trg.Context.RawData,
+	}
+	return json.Marshal(jt)
+}
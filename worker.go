@@ -1,38 +1,61 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"net/http"
+	"sync"
+	"time"
 	"triggerflow/config"
 	"triggerflow/eventsource"
 	"triggerflow/tirggerstorage"
 	"triggerflow/trigger"
+	"triggerflow/workflow"
 
 	cloudevents "github.com/cloudevents/sdk-go"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	log "github.com/sirupsen/logrus"
+	"golang.org/x/time/rate"
 )
 
 type Workspace struct {
 	WorkspaceName       string
 	Triggers            trigger.Map
 	TriggerEventMapping trigger.ActivationEventMap
-	GlobalContext       map[string]map[string]interface{}
+	GlobalContext       *trigger.GlobalContext
 	TriggerStorage      tirggerstorage.Storage
 	EventSources        map[string]eventsource.EventSource
 	EventSink           chan *cloudevents.Event
-	CheckpointChannel   chan *trigger.Trigger
+	CheckpointChannel   chan checkpointRecord
+	WorkflowRunner      *workflow.Runner
+	committer           *committer
+	metricsServer       *http.Server
+
+	// inFlight tracks trigger actions currently executing, so shutdown
+	// can wait for them to drain instead of cutting them off.
+	inFlight sync.WaitGroup
+
+	// mu guards Triggers and TriggerEventMapping, which watchTriggers
+	// mutates concurrently with updateTriggers, the dispatch loop and
+	// shutdown's checkpoint sweep.
+	mu sync.RWMutex
 }
 
-func ProcessWorkspace(workspaceName string) {
+// ProcessWorkspace runs workspaceName until ctx is canceled. On
+// cancellation it stops accepting new events, drains in-flight trigger
+// actions up to config.ShutdownGracePeriod, checkpoints whatever triggers
+// are left, then closes every event source.
+func ProcessWorkspace(ctx context.Context, workspaceName string) {
 	workspace := Workspace{
 		WorkspaceName:       workspaceName,
 		Triggers:            make(trigger.Map),
 		TriggerEventMapping: make(trigger.ActivationEventMap),
 		EventSources:        make(map[string]eventsource.EventSource),
 		EventSink:           make(chan *cloudevents.Event, config.SinkMaxSize),
-		GlobalContext:       make(map[string]map[string]interface{}),
-		CheckpointChannel:   make(chan *trigger.Trigger),
+		GlobalContext:       trigger.NewGlobalContext(),
+		CheckpointChannel:   make(chan checkpointRecord, config.SinkMaxSize),
 	}
 
 	workspace.startTriggerStorage()
@@ -49,49 +72,188 @@ func ProcessWorkspace(workspaceName string) {
 		if err != nil {
 			panic(err)
 		}
-		workspace.GlobalContext[key] = parsedValue
+		workspace.GlobalContext.SetNamespace(key, parsedValue)
 	}
 
+	workspace.WorkflowRunner = workflow.NewRunner(workspaceName, workspace.TriggerStorage, workspace.EventSink, workspace.GlobalContext)
+
 	workspace.startEventSources()
+	workspace.startMetricsServer()
+
+	comm, err := newCommitter(&workspace)
+	if err != nil {
+		panic(err)
+	}
+	workspace.committer = comm
+	comm.replay()
+	go comm.run()
+
 	workspace.updateTriggers()
 
+	workspace.mu.RLock()
 	for _, trg := range workspace.Triggers {
-		go workspace.processTrigger(trg)
+		go workspace.processTrigger(ctx, trg)
 	}
-
-	for event := range workspace.EventSink {
-		if matchingTriggers, ok := workspace.TriggerEventMapping[event.Subject()][event.Type()]; ok {
-			for _, trg := range matchingTriggers {
-				trg.EventChannel <- event
+	workspace.mu.RUnlock()
+
+	go workspace.watchTriggers(ctx)
+
+dispatch:
+	for {
+		select {
+		case <-ctx.Done():
+			break dispatch
+		case event := <-workspace.EventSink:
+			workspace.mu.RLock()
+			matchingTriggers, ok := workspace.TriggerEventMapping[event.Subject()][event.Type()]
+			workspace.mu.RUnlock()
+
+			if ok {
+				for _, trg := range matchingTriggers {
+					trg.Enqueue(event)
+				}
+			} else {
+				log.Infof("Received event with subject <%s> and type <%s> not found in local trigger cache", event.Subject(), event.Type())
+				workspace.updateTriggers()
+				workspace.EventSink <- event
 			}
-		} else {
-			log.Infof("Received event with subject <%s> and type <%s> not found in local trigger cache", event.Subject(), event.Type())
-			workspace.updateTriggers()
-			workspace.EventSink <- event
 		}
 	}
+
+	workspace.shutdown()
 }
 
-func (workspace *Workspace) processTrigger(trg *trigger.Trigger) {
-	for event := range trg.EventChannel {
-		log.Debugf("Processing trigger <%s>", trg.TriggerID)
-		condition, err := trg.Condition(trg.Context, *event)
+// shutdown waits for in-flight trigger actions to drain (bounded by
+// config.ShutdownGracePeriod), checkpoints whatever triggers remain, then
+// closes every event source.
+func (workspace *Workspace) shutdown() {
+	log.Infof("Shutting down workspace <%s>", workspace.WorkspaceName)
+
+	drained := make(chan struct{})
+	go func() {
+		workspace.inFlight.Wait()
+		close(drained)
+	}()
+
+	select {
+	case <-drained:
+		log.Infof("All in-flight trigger actions drained")
+	case <-time.After(config.ShutdownGracePeriod):
+		log.Warnf("Shutdown grace period elapsed with trigger actions still in flight")
+	}
 
-		if err != nil {
-			log.Errorf("Error while processing <%s> condition: %s", trg.TriggerID, err)
-			return
+	close(workspace.CheckpointChannel)
+	<-workspace.committer.done
+	if err := workspace.committer.close(); err != nil {
+		log.Errorf("Error closing checkpoint WAL: %s", err)
+	}
+
+	workspace.mu.RLock()
+	for _, trg := range workspace.Triggers {
+		workspace.checkpointTrigger(trg)
+	}
+	workspace.mu.RUnlock()
+
+	for name, source := range workspace.EventSources {
+		if closer, ok := source.(interface{ Close() error }); ok {
+			if err := closer.Close(); err != nil {
+				log.Errorf("Error closing event source <%s>: %s", name, err)
+			}
 		}
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), config.ShutdownGracePeriod)
+	defer cancel()
+	if err := workspace.metricsServer.Shutdown(shutdownCtx); err != nil {
+		log.Errorf("Error closing metrics server: %s", err)
+	}
+}
+
+// processTrigger spawns trg.Concurrency workers draining trg.EventChannel
+// in parallel, each gated by trg.RateLimit, so a single slow action no
+// longer blocks every other event queued for the trigger. Workers stop
+// pulling new events once ctx is canceled.
+func (workspace *Workspace) processTrigger(ctx context.Context, trg *trigger.Trigger) {
+	limiter := trg.Limiter()
+
+	var wg sync.WaitGroup
+	for i := 0; i < trg.Concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			workspace.runTriggerWorker(ctx, trg, limiter)
+		}()
+	}
+	wg.Wait()
+}
 
-		if condition {
-			err = trg.Action(trg.Context, *event)
-			if err != nil {
-				log.Errorf("Error while processing <%s> action: %s", trg.TriggerID, err)
+func (workspace *Workspace) runTriggerWorker(ctx context.Context, trg *trigger.Trigger, limiter *rate.Limiter) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event, ok := <-trg.EventChannel:
+			if !ok {
 				return
 			}
+			if limiter != nil {
+				if err := limiter.Wait(ctx); err != nil {
+					return
+				}
+			}
+			workspace.fireTrigger(ctx, trg, event)
+		}
+	}
+}
+
+// fireTrigger evaluates trg.Condition and, if it matches, runs trg.Action
+// bounded by trg.Timeout (if set) and ctx. It tracks the invocation in
+// workspace.inFlight so shutdown can wait for it to finish.
+func (workspace *Workspace) fireTrigger(ctx context.Context, trg *trigger.Trigger, event *cloudevents.Event) {
+	log.Debugf("Processing trigger <%s>", trg.TriggerID)
+	condition, err := trg.Condition(trg.Context, *event)
+
+	if err != nil {
+		log.Errorf("Error while processing <%s> condition: %s", trg.TriggerID, err)
+		return
+	}
 
-			log.Infof("Trigger %s action fired", trg.TriggerID)
-			go workspace.checkpointTriggers()
+	if !condition {
+		return
+	}
+
+	actionCtx := ctx
+	if trg.Timeout > 0 {
+		var cancel context.CancelFunc
+		actionCtx, cancel = context.WithTimeout(ctx, trg.Timeout)
+		defer cancel()
+	}
+
+	// inFlight is only marked done once the action goroutine itself
+	// returns, not once fireTrigger stops waiting on it -- otherwise a
+	// hung action past its deadline would no longer be "in flight" as
+	// far as shutdown's drain is concerned.
+	workspace.inFlight.Add(1)
+	result := make(chan error, 1)
+	start := time.Now()
+	go func() {
+		defer workspace.inFlight.Done()
+		result <- trg.Action(actionCtx, trg.Context, *event)
+	}()
+
+	select {
+	case err := <-result:
+		trg.ObserveActionLatency(time.Since(start).Seconds())
+		if err != nil {
+			log.Errorf("Error while processing <%s> action: %s", trg.TriggerID, err)
+			return
 		}
+		log.Infof("Trigger %s action fired", trg.TriggerID)
+		workspace.WorkflowRunner.OnTriggerFired(trg, *event)
+		workspace.enqueueCheckpoint(trg, event)
+	case <-actionCtx.Done():
+		trg.ObserveActionLatency(time.Since(start).Seconds())
+		log.Errorf("Trigger <%s> action did not complete before its deadline: %s", trg.TriggerID, actionCtx.Err())
 	}
 }
 
@@ -100,35 +262,128 @@ func (workspace *Workspace) updateTriggers() {
 	allTriggers := workspace.TriggerStorage.Get((*workspace).WorkspaceName, "triggers")
 
 	for triggerID, triggerJSON := range allTriggers {
-		if _, ok := workspace.Triggers[triggerID]; !ok {
+		workspace.mu.RLock()
+		_, exists := workspace.Triggers[triggerID]
+		workspace.mu.RUnlock()
+		if exists {
+			continue
+		}
 
-			newTrigger, err := trigger.UnmarshalJSONTrigger([]byte(triggerJSON))
-			if err != nil {
-				log.Errorf("Encountered error during JSON Trigger unmarshal: %s", err)
-				continue
-			}
+		newTrigger, err := trigger.UnmarshalJSONTrigger([]byte(triggerJSON))
+		if err != nil {
+			log.Errorf("Encountered error during JSON Trigger unmarshal: %s", err)
+			continue
+		}
 
-			workspace.contextualizeTrigger(newTrigger)
-			workspace.Triggers[newTrigger.TriggerID] = newTrigger
+		workspace.contextualizeTrigger(newTrigger)
+		workspace.addTrigger(newTrigger)
+	}
 
-			for _, actEvt := range newTrigger.ActivationEvents {
-				if _, ok := workspace.TriggerEventMapping[actEvt.Subject()]; !ok {
-					workspace.TriggerEventMapping[actEvt.Subject()] = make(map[string][]*trigger.Trigger)
-				}
+	workspace.mu.RLock()
+	log.Infof("Triggers updated -- %d triggers in local cache", len(workspace.Triggers))
+	workspace.mu.RUnlock()
+}
 
-				if _, ok := workspace.TriggerEventMapping[actEvt.Subject()][actEvt.Type()]; !ok {
-					workspace.TriggerEventMapping[actEvt.Subject()][actEvt.Type()] = make([]*trigger.Trigger, 0)
-				}
+// addTrigger registers newTrigger in Triggers and TriggerEventMapping. Shared
+// by updateTriggers and watchTriggers so both cache-miss polling and
+// push-based hot reload keep the two maps in sync the same way.
+func (workspace *Workspace) addTrigger(newTrigger *trigger.Trigger) {
+	workspace.mu.Lock()
+	defer workspace.mu.Unlock()
+
+	workspace.Triggers[newTrigger.TriggerID] = newTrigger
 
-				trgIDs := workspace.TriggerEventMapping[actEvt.Subject()][actEvt.Type()]
-				workspace.TriggerEventMapping[actEvt.Subject()][actEvt.Type()] = append(trgIDs, newTrigger)
+	for _, actEvt := range newTrigger.ActivationEvents {
+		if _, ok := workspace.TriggerEventMapping[actEvt.Subject()]; !ok {
+			workspace.TriggerEventMapping[actEvt.Subject()] = make(map[string][]*trigger.Trigger)
+		}
+
+		if _, ok := workspace.TriggerEventMapping[actEvt.Subject()][actEvt.Type()]; !ok {
+			workspace.TriggerEventMapping[actEvt.Subject()][actEvt.Type()] = make([]*trigger.Trigger, 0)
+		}
+
+		trgIDs := workspace.TriggerEventMapping[actEvt.Subject()][actEvt.Type()]
+		workspace.TriggerEventMapping[actEvt.Subject()][actEvt.Type()] = append(trgIDs, newTrigger)
+	}
+
+	log.Debugf("Added new trigger to cache: <%s> <%s>", newTrigger.TriggerID, newTrigger.UUID)
+}
+
+// watchTriggers subscribes to TriggerStorage's push notifications for the
+// workspace's "triggers" collection and applies each add/update/delete as it
+// arrives, so new and changed triggers take effect immediately instead of
+// waiting for the next cache-miss-triggered updateTriggers call. It returns
+// once ctx is canceled or the storage backend closes the watch channel.
+func (workspace *Workspace) watchTriggers(ctx context.Context) {
+	changes := workspace.TriggerStorage.Watch(workspace.WorkspaceName, "triggers")
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case change, ok := <-changes:
+			if !ok {
+				return
 			}
+			workspace.applyTriggerChange(ctx, change)
+		}
+	}
+}
 
-			log.Debugf("Added new trigger to cache: <%s> <%s>", newTrigger.TriggerID, newTrigger.UUID)
+func (workspace *Workspace) applyTriggerChange(ctx context.Context, change tirggerstorage.StorageEvent) {
+	switch change.Type {
+	case tirggerstorage.StorageEventDelete:
+		workspace.removeTrigger(change.Key)
+
+	case tirggerstorage.StorageEventPut:
+		newTrigger, err := trigger.UnmarshalJSONTrigger([]byte(change.Value))
+		if err != nil {
+			log.Errorf("watchTriggers: could not unmarshal trigger <%s>: %s", change.Key, err)
+			return
+		}
+
+		// A put on an already-cached trigger is an update: tear down its
+		// old worker pool and mapping entries first so it isn't left
+		// running stale config alongside the new one.
+		workspace.mu.RLock()
+		_, existed := workspace.Triggers[newTrigger.TriggerID]
+		workspace.mu.RUnlock()
+		if existed {
+			workspace.removeTrigger(newTrigger.TriggerID)
 		}
+
+		workspace.contextualizeTrigger(newTrigger)
+		workspace.addTrigger(newTrigger)
+		go workspace.processTrigger(ctx, newTrigger)
 	}
+}
 
-	log.Infof("Triggers updated -- %d triggers in local cache", len(workspace.Triggers))
+// removeTrigger drops triggerID from the cache and closes its EventChannel,
+// so any runTriggerWorker goroutines still waiting on it exit instead of
+// leaking. trg.Close() coordinates with any Enqueue call racing the
+// deletion so dispatch can never send on the now-closed channel.
+func (workspace *Workspace) removeTrigger(triggerID string) {
+	workspace.mu.Lock()
+	defer workspace.mu.Unlock()
+
+	trg, ok := workspace.Triggers[triggerID]
+	if !ok {
+		return
+	}
+	delete(workspace.Triggers, triggerID)
+
+	for _, actEvt := range trg.ActivationEvents {
+		trgs := workspace.TriggerEventMapping[actEvt.Subject()][actEvt.Type()]
+		for i, t := range trgs {
+			if t.TriggerID == triggerID {
+				workspace.TriggerEventMapping[actEvt.Subject()][actEvt.Type()] = append(trgs[:i], trgs[i+1:]...)
+				break
+			}
+		}
+	}
+
+	trg.Close()
+	log.Debugf("Removed trigger from cache: <%s>", triggerID)
 }
 
 func (workspace *Workspace) contextualizeTrigger(trg *trigger.Trigger) {
@@ -182,22 +437,58 @@ func (workspace *Workspace) startEventSources() {
 
 		// Instantiate EventSource and start consuming events
 		EventSource := eventsource.Constructors[eventSourceMeta.Class]
-		workspace.EventSources[eventSourceMeta.Name] = EventSource(workspace.WorkspaceName, workspace.EventSink, eventSourceMeta.Parameters)
+		workspace.EventSources[eventSourceMeta.Name] = EventSource(workspace.WorkspaceName, eventSourceMeta.Name, workspace.EventSink, eventSourceMeta.Parameters)
 		go workspace.EventSources[eventSourceMeta.Name].StartConsuming()
 	}
 }
 
-func (workspace *Workspace) checkpointTriggers() {
-	for _, eventSource := range workspace.EventSources {
-		go eventSource.CommitEvents()
+// startMetricsServer serves the trigger package's Prometheus collectors
+// (queue depth, drops, action latency) on config.Map.MetricsAddr so they're
+// actually scrapeable, not just tracked in-process.
+func (workspace *Workspace) startMetricsServer() {
+	addr := config.Map.MetricsAddr
+	if addr == "" {
+		addr = config.DefaultMetricsAddr
 	}
 
-	for trg := range workspace.CheckpointChannel {
-		encodedTrigger, err := trigger.MarshalJSONTrigger(trg)
-		if err != nil {
-			log.Errorf("Could not checkpoint trigger %s", trg.TriggerID)
-		} else {
-			go workspace.TriggerStorage.Put(workspace.WorkspaceName, "triggers", trg.TriggerID, encodedTrigger)
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+	workspace.metricsServer = &http.Server{Addr: addr, Handler: mux}
+
+	go func() {
+		if err := workspace.metricsServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Errorf("Metrics server error: %s", err)
 		}
+	}()
+}
+
+// enqueueCheckpoint hands a fired trigger off to the background committer.
+// The trigger is marshaled eagerly so the WAL record is self-contained and
+// replaying it never depends on the in-memory trigger cache being warm.
+func (workspace *Workspace) enqueueCheckpoint(trg *trigger.Trigger, event *cloudevents.Event) {
+	encodedTrigger, err := trigger.MarshalJSONTrigger(trg)
+	if err != nil {
+		log.Errorf("Could not encode trigger <%s> for checkpoint: %s", trg.TriggerID, err)
+		return
+	}
+
+	sourceName, offset := eventCheckpointInfo(event)
+	workspace.CheckpointChannel <- checkpointRecord{
+		TriggerID:       trg.TriggerID,
+		TriggerJSON:     string(encodedTrigger),
+		EventSourceName: sourceName,
+		Offset:          offset,
+	}
+}
+
+// checkpointTrigger persists a single trigger synchronously, bypassing the
+// committer. Used at shutdown, where every remaining trigger must be
+// flushed before the process exits rather than waiting on its next batch.
+func (workspace *Workspace) checkpointTrigger(trg *trigger.Trigger) {
+	encodedTrigger, err := trigger.MarshalJSONTrigger(trg)
+	if err != nil {
+		log.Errorf("Could not checkpoint trigger <%s>: %s", trg.TriggerID, err)
+		return
 	}
+	workspace.TriggerStorage.Put(workspace.WorkspaceName, "triggers", trg.TriggerID, string(encodedTrigger))
 }
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"triggerflow/trigger"
+
+	cloudevents "github.com/cloudevents/sdk-go"
+)
+
+// TestFireTriggerInFlightTracksActionCompletion guards against a
+// regression where workspace.inFlight.Done() fired as soon as fireTrigger
+// stopped waiting on a timed-out action, rather than when the action
+// goroutine itself returned -- which made shutdown's inFlight.Wait() stop
+// waiting for actions that were still actually running.
+func TestFireTriggerInFlightTracksActionCompletion(t *testing.T) {
+	actionFinished := make(chan struct{})
+
+	trg := &trigger.Trigger{
+		Timeout: 10 * time.Millisecond,
+		Condition: func(_ trigger.Context, _ cloudevents.Event) (bool, error) {
+			return true, nil
+		},
+		Action: func(runCtx context.Context, _ trigger.Context, _ cloudevents.Event) error {
+			<-runCtx.Done()
+			time.Sleep(50 * time.Millisecond)
+			close(actionFinished)
+			return runCtx.Err()
+		},
+	}
+
+	event := cloudevents.NewEvent()
+	event.SetID("1")
+	event.SetSubject("subj")
+	event.SetType("some.event")
+
+	workspace := &Workspace{}
+	workspace.fireTrigger(context.Background(), trg, &event)
+
+	select {
+	case <-actionFinished:
+		t.Fatal("action goroutine finished before fireTrigger returned; test can't tell Done() was deferred properly")
+	default:
+	}
+
+	drained := make(chan struct{})
+	go func() {
+		workspace.inFlight.Wait()
+		close(drained)
+	}()
+
+	select {
+	case <-drained:
+	case <-time.After(time.Second):
+		t.Fatal("inFlight.Wait() did not return once the action goroutine actually finished")
+	}
+
+	select {
+	case <-actionFinished:
+	default:
+		t.Fatal("inFlight.Wait() returned before the action goroutine finished")
+	}
+}
@@ -0,0 +1,211 @@
+package workflow
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"triggerflow/tirggerstorage"
+	"triggerflow/trigger"
+
+	cloudevents "github.com/cloudevents/sdk-go"
+	log "github.com/sirupsen/logrus"
+)
+
+// InstanceState is the crash-recoverable progress of a single workflow
+// instance: which nodes (by instanceNodeID, so matrix combinations are
+// tracked individually) have completed so far.
+type InstanceState struct {
+	WorkflowID     string          `json:"workflowId"`
+	CorrelationKey string          `json:"correlationKey"`
+	Completed      map[string]bool `json:"completed"`
+}
+
+// Runner tracks Definitions loaded from TriggerStorage and advances
+// workflow instances as their triggers fire, emitting synthetic CloudEvents
+// on EventSink so downstream triggers activate through the normal
+// TriggerEventMapping path.
+type Runner struct {
+	workspaceName string
+	storage       tirggerstorage.Storage
+	sink          chan *cloudevents.Event
+	globalContext *trigger.GlobalContext
+
+	mu             sync.RWMutex
+	definitions    map[string]*Definition
+	triggerIndex   map[string][]*Definition
+	instances      map[string]*InstanceState
+	compiledGuards map[string]trigger.ConditionFunc
+}
+
+// NewRunner loads every workflow Definition and any recoverable instance
+// state for workspaceName, ready to receive OnTriggerFired calls.
+// globalContext is the workspace's GlobalContext, threaded through so "if"
+// guards can reference it the same way a Trigger's ConditionExpr can.
+func NewRunner(workspaceName string, storage tirggerstorage.Storage, sink chan *cloudevents.Event, globalContext *trigger.GlobalContext) *Runner {
+	r := &Runner{
+		workspaceName:  workspaceName,
+		storage:        storage,
+		sink:           sink,
+		globalContext:  globalContext,
+		definitions:    make(map[string]*Definition),
+		triggerIndex:   make(map[string][]*Definition),
+		instances:      make(map[string]*InstanceState),
+		compiledGuards: make(map[string]trigger.ConditionFunc),
+	}
+	r.loadDefinitions()
+	r.recoverInstances()
+	return r
+}
+
+func (r *Runner) loadDefinitions() {
+	stored := r.storage.Get(r.workspaceName, "workflows")
+	for workflowID, raw := range stored {
+		var def Definition
+		if err := json.Unmarshal([]byte(raw), &def); err != nil {
+			log.Errorf("workflow: invalid definition <%s>: %s", workflowID, err)
+			continue
+		}
+		def.WorkflowID = workflowID
+
+		d := def
+		r.definitions[workflowID] = &d
+		for _, node := range d.Nodes {
+			r.triggerIndex[node.TriggerID] = append(r.triggerIndex[node.TriggerID], &d)
+		}
+	}
+}
+
+func (r *Runner) recoverInstances() {
+	stored := r.storage.Get(r.workspaceName, "workflow_instances")
+	for instanceKey, raw := range stored {
+		var inst InstanceState
+		if err := json.Unmarshal([]byte(raw), &inst); err != nil {
+			log.Errorf("workflow: invalid instance state <%s>: %s", instanceKey, err)
+			continue
+		}
+		r.instances[instanceKey] = &inst
+	}
+}
+
+// OnTriggerFired is called once a Trigger's Action has fired successfully.
+// It advances every workflow node backed by that trigger and activates any
+// dependents whose Needs/If are now satisfied.
+func (r *Runner) OnTriggerFired(trg *trigger.Trigger, event cloudevents.Event) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, def := range r.triggerIndex[trg.TriggerID] {
+		for _, node := range def.nodesByTrigger(trg.TriggerID) {
+			r.completeNode(def, node, event)
+		}
+	}
+}
+
+func (r *Runner) completeNode(def *Definition, node Node, event cloudevents.Event) {
+	// The correlation key ties together every node firing for the same
+	// logical run of the workflow; the event's Subject is the natural
+	// choice since triggers are already matched on it.
+	correlationKey := event.Subject()
+	instanceKey := def.WorkflowID + "/" + correlationKey
+
+	inst, ok := r.instances[instanceKey]
+	if !ok {
+		inst = &InstanceState{
+			WorkflowID:     def.WorkflowID,
+			CorrelationKey: correlationKey,
+			Completed:      make(map[string]bool),
+		}
+		r.instances[instanceKey] = inst
+	}
+
+	// Every matrix combination of node fires together here, so the node
+	// as a whole is complete too: record the bare ID alongside each
+	// instantiated one so dependents can check their Needs against it
+	// without having to know node's Matrix.
+	inst.Completed[node.ID] = true
+	for _, suffix := range matrixKeys(node) {
+		inst.Completed[instanceNodeID(node.ID, suffix)] = true
+	}
+
+	r.persistInstance(instanceKey, inst)
+	r.activateDependents(def, inst, node, event)
+}
+
+func (r *Runner) activateDependents(def *Definition, inst *InstanceState, completed Node, event cloudevents.Event) {
+	for _, dependent := range def.dependents(completed.ID) {
+		if !r.needsSatisfied(inst, dependent) {
+			continue
+		}
+		if !r.guardPasses(dependent, event) {
+			continue
+		}
+		r.emitNodeReady(def, inst, dependent)
+	}
+}
+
+func (r *Runner) needsSatisfied(inst *InstanceState, node Node) bool {
+	for _, need := range node.Needs {
+		if !inst.Completed[need] {
+			return false
+		}
+	}
+	return true
+}
+
+func (r *Runner) guardPasses(node Node, event cloudevents.Event) bool {
+	if node.If == "" {
+		return true
+	}
+
+	guard, err := r.compiledGuard(node.If)
+	if err != nil {
+		log.Errorf("workflow: bad guard %q on node <%s>: %s", node.If, node.ID, err)
+		return false
+	}
+
+	passed, err := guard(trigger.Context{GlobalContext: r.globalContext}, event)
+	if err != nil {
+		log.Errorf("workflow: guard %q on node <%s> failed: %s", node.If, node.ID, err)
+		return false
+	}
+	return passed
+}
+
+func (r *Runner) compiledGuard(expr string) (trigger.ConditionFunc, error) {
+	if fn, ok := r.compiledGuards[expr]; ok {
+		return fn, nil
+	}
+
+	fn, err := trigger.CompileCondition(expr)
+	if err != nil {
+		return nil, err
+	}
+	r.compiledGuards[expr] = fn
+	return fn, nil
+}
+
+// emitNodeReady publishes one synthetic CloudEvent per matrix combination
+// of node, addressed so that triggers whose ActivationEvents reference it
+// pick it up through the usual TriggerEventMapping path.
+func (r *Runner) emitNodeReady(def *Definition, inst *InstanceState, node Node) {
+	for _, suffix := range matrixKeys(node) {
+		out := cloudevents.NewEvent()
+		out.SetSubject(inst.CorrelationKey)
+		out.SetType(fmt.Sprintf("workflow.%s.node.%s.ready", def.WorkflowID, node.ID))
+		if err := out.SetData(map[string]string{"matrix": suffix}); err != nil {
+			log.Errorf("workflow: could not set node-ready data: %s", err)
+			continue
+		}
+		r.sink <- &out
+	}
+}
+
+func (r *Runner) persistInstance(instanceKey string, inst *InstanceState) {
+	encoded, err := json.Marshal(inst)
+	if err != nil {
+		log.Errorf("workflow: could not encode instance <%s>: %s", instanceKey, err)
+		return
+	}
+	r.storage.Put(r.workspaceName, "workflow_instances", instanceKey, string(encoded))
+}
@@ -0,0 +1,69 @@
+package workflow_test
+
+import (
+	"testing"
+	"time"
+
+	"triggerflow/tirggerstorage"
+	"triggerflow/trigger"
+	"triggerflow/workflow"
+
+	cloudevents "github.com/cloudevents/sdk-go"
+)
+
+type fakeStorage struct {
+	collections map[string]map[string]string
+}
+
+func (f *fakeStorage) Get(workspace, collection string) map[string]string {
+	return f.collections[workspace+"/"+collection]
+}
+
+func (f *fakeStorage) Put(workspace, collection, key, value string) {}
+
+func (f *fakeStorage) Watch(workspace, collection string) <-chan tirggerstorage.StorageEvent {
+	return make(chan tirggerstorage.StorageEvent)
+}
+
+// TestRunnerGuardWithGlobalContext guards against a regression where
+// guardPasses evaluated "if" guards against a zero-value trigger.Context,
+// whose nil GlobalContext panicked the moment an expression referenced
+// "context". A guard must be able to read the workspace's GlobalContext
+// like a Trigger's ConditionExpr can.
+func TestRunnerGuardWithGlobalContext(t *testing.T) {
+	def := `{
+		"name": "test",
+		"nodes": [
+			{"id": "a", "triggerId": "t-a"},
+			{"id": "b", "triggerId": "t-b", "needs": ["a"], "if": "size(context) > 0"}
+		]
+	}`
+
+	storage := &fakeStorage{
+		collections: map[string]map[string]string{
+			"ws/workflows": {"wf1": def},
+		},
+	}
+
+	globalContext := trigger.NewGlobalContext()
+	globalContext.Set("ns", "key", "value")
+
+	sink := make(chan *cloudevents.Event, 1)
+	runner := workflow.NewRunner("ws", storage, sink, globalContext)
+
+	event := cloudevents.NewEvent()
+	event.SetID("1")
+	event.SetSubject("run-1")
+	event.SetType("some.event")
+
+	runner.OnTriggerFired(&trigger.Trigger{TriggerID: "t-a"}, event)
+
+	select {
+	case out := <-sink:
+		if out.Subject() != "run-1" {
+			t.Fatalf("node-ready event has subject %q, want %q", out.Subject(), "run-1")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("node b never activated: guard evaluation against GlobalContext likely panicked or was skipped")
+	}
+}
@@ -0,0 +1,106 @@
+// Package workflow composes existing triggers into DAGs: a workflow node
+// fires its referenced trigger, and downstream nodes activate once their
+// "needs" are satisfied and their optional "if" guard passes.
+package workflow
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Definition is a DAG of trigger nodes, stored alongside plain triggers in
+// TriggerStorage under the "workflows" collection.
+type Definition struct {
+	WorkflowID string `json:"workflowId"`
+	Name       string `json:"name"`
+	Nodes      []Node `json:"nodes"`
+}
+
+// Node references an existing trigger by ID. It only runs once every ID in
+// Needs has completed for the same workflow instance, and once its If
+// guard (if any) evaluates true against the event that completed the last
+// of its dependencies.
+type Node struct {
+	ID        string              `json:"id"`
+	TriggerID string              `json:"triggerId"`
+	Needs     []string            `json:"needs,omitempty"`
+	If        string              `json:"if,omitempty"`
+	Matrix    map[string][]string `json:"matrix,omitempty"`
+}
+
+func (d *Definition) node(id string) (Node, bool) {
+	for _, n := range d.Nodes {
+		if n.ID == id {
+			return n, true
+		}
+	}
+	return Node{}, false
+}
+
+func (d *Definition) nodesByTrigger(triggerID string) []Node {
+	var matches []Node
+	for _, n := range d.Nodes {
+		if n.TriggerID == triggerID {
+			matches = append(matches, n)
+		}
+	}
+	return matches
+}
+
+// dependents returns the nodes whose Needs include nodeID.
+func (d *Definition) dependents(nodeID string) []Node {
+	var matches []Node
+	for _, n := range d.Nodes {
+		for _, need := range n.Needs {
+			if need == nodeID {
+				matches = append(matches, n)
+				break
+			}
+		}
+	}
+	return matches
+}
+
+// matrixKeys expands a node's Matrix into one key suffix per combination,
+// e.g. {"os": ["linux","darwin"], "arch": ["amd64"]} ->
+// ["arch=amd64,os=linux", "arch=amd64,os=darwin"]. A node without a Matrix
+// expands to a single, empty suffix. Dimension keys are sorted before
+// combos are built so the same logical combination always serializes to
+// the same suffix string, regardless of Go's randomized map iteration
+// order across calls.
+func matrixKeys(node Node) []string {
+	if len(node.Matrix) == 0 {
+		return []string{""}
+	}
+
+	keys := make([]string, 0, len(node.Matrix))
+	for k := range node.Matrix {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	combos := []string{""}
+	for _, k := range keys {
+		var next []string
+		for _, combo := range combos {
+			for _, v := range node.Matrix[k] {
+				entry := fmt.Sprintf("%s=%s", k, v)
+				if combo != "" {
+					entry = combo + "," + entry
+				}
+				next = append(next, entry)
+			}
+		}
+		combos = next
+	}
+	return combos
+}
+
+// instanceNodeID identifies a single matrix combination of a node within a
+// workflow instance.
+func instanceNodeID(nodeID, matrixSuffix string) string {
+	if matrixSuffix == "" {
+		return nodeID
+	}
+	return nodeID + "#" + matrixSuffix
+}